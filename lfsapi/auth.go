@@ -0,0 +1,108 @@
+package lfsapi
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/git-lfs/git-lfs/errors"
+)
+
+// Creds represents a set of key/value pairs describing a credential, using
+// the same field names as the `git credential` protocol (e.g. "protocol",
+// "host", "username", "password").
+type Creds map[string]string
+
+// CredentialHelper is implemented by anything that can fill in, and later
+// approve or reject, a Creds value for an LFS endpoint. commandCredentialHelper
+// shells out to `git credential`; jwtCredentialHelper mints bearer tokens
+// instead of prompting for a username and password.
+type CredentialHelper interface {
+	Fill(Creds) (Creds, error)
+	Reject(Creds) error
+	Approve(Creds) error
+}
+
+// commandCredentialHelper fills, approves, and rejects credentials using the
+// `git credential` command, the same helper chain `git` itself uses.
+type commandCredentialHelper struct {
+	SkipPrompt bool
+}
+
+func (h *commandCredentialHelper) Fill(creds Creds) (Creds, error) {
+	return h.exec("fill", creds)
+}
+
+func (h *commandCredentialHelper) Approve(creds Creds) error {
+	_, err := h.exec("approve", creds)
+	return err
+}
+
+func (h *commandCredentialHelper) Reject(creds Creds) error {
+	_, err := h.exec("reject", creds)
+	return err
+}
+
+func (h *commandCredentialHelper) exec(subcommand string, input Creds) (Creds, error) {
+	cmd := exec.Command("git", "credential", subcommand)
+	cmd.Stdin = strings.NewReader(credsToInput(input))
+
+	if h.SkipPrompt {
+		// This is how git itself suppresses interactive credential
+		// prompts (see `git help credential`); there is no
+		// "askpass" key in the credential protocol.
+		cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	}
+
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "'git credential %s' failed", subcommand)
+	}
+
+	return credsFromOutput(stdout.Bytes()), nil
+}
+
+func credsToInput(creds Creds) string {
+	var buf bytes.Buffer
+	for k, v := range creds {
+		fmt.Fprintf(&buf, "%s=%s\n", k, v)
+	}
+	return buf.String()
+}
+
+// setRequestAuth applies the scheme implied by creds to req. Most
+// CredentialHelpers fill in "username"/"password" for HTTP Basic auth;
+// jwtCredentialHelper instead sets "authtype" to "Bearer" and "password" to a
+// signed token, which is attached as an Authorization: Bearer header.
+func setRequestAuth(req *http.Request, creds Creds) {
+	if creds == nil {
+		return
+	}
+
+	if strings.EqualFold(creds["authtype"], "Bearer") {
+		req.Header.Set("Authorization", "Bearer "+creds["password"])
+		return
+	}
+
+	req.SetBasicAuth(creds["username"], creds["password"])
+}
+
+func credsFromOutput(by []byte) Creds {
+	creds := make(Creds)
+	scanner := bufio.NewScanner(bytes.NewReader(by))
+	for scanner.Scan() {
+		line := scanner.Text()
+		idx := strings.Index(line, "=")
+		if idx < 0 {
+			continue
+		}
+		creds[line[:idx]] = line[idx+1:]
+	}
+	return creds
+}
@@ -0,0 +1,141 @@
+package lfsapi
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// httpTransfer records what the built-in stats Middleware observed about a
+// single round trip: which request it was, how it was answered, how many
+// bytes moved in each direction, and how long it took. Response is nil for
+// requests that failed before a response was received.
+type httpTransfer struct {
+	Key          string
+	Method       string
+	Status       int
+	RequestSize  int64
+	ResponseSize int64
+	Start        time.Time
+	Dur          time.Duration
+	Auth         string
+	Retry        bool
+	Endpoint     string
+	Response     *http.Response
+}
+
+// LogStats records t against the Client's transfer history -- keyed by
+// *http.Response in c.transfers, and bucketed by sanitized URL in
+// c.transferBuckets, the same way progress meters and `git lfs logs last`
+// look up a response's metadata -- and writes a logfmt-encoded StatsEntry to
+// StatsWriter (falling back to VerboseOut), so the stream can be parsed
+// deterministically with ParseStatsLine instead of regex-scraped. Embedders
+// that want metrics delivered somewhere other than a writer (e.g. a
+// prom-metrics sink) should register their own Middleware via Use instead of
+// relying on this output.
+func (c *Client) LogStats(t *httpTransfer) {
+	if t.Response != nil {
+		c.transferMu.Lock()
+		if c.transfers == nil {
+			c.transfers = make(map[*http.Response]*httpTransfer)
+		}
+		c.transfers[t.Response] = t
+		c.transferMu.Unlock()
+
+		c.transferBucketMu.Lock()
+		if c.transferBuckets == nil {
+			c.transferBuckets = make(map[string][]*http.Response)
+		}
+		c.transferBuckets[t.Key] = append(c.transferBuckets[t.Key], t.Response)
+		c.transferBucketMu.Unlock()
+	}
+
+	w := c.StatsWriter
+	if w == nil {
+		w = c.VerboseOut
+	}
+	if w == nil {
+		return
+	}
+
+	entry := &StatsEntry{
+		Timestamp:  t.Start,
+		Method:     t.Method,
+		URL:        t.Key,
+		Status:     t.Status,
+		ReqBytes:   t.RequestSize,
+		RespBytes:  t.ResponseSize,
+		DurationMS: t.Dur.Nanoseconds() / int64(time.Millisecond),
+		Auth:       t.Auth,
+		Retry:      t.Retry,
+		Endpoint:   t.Endpoint,
+	}
+
+	// Multiple transfers run concurrently through one Client, so without
+	// this lock concurrent onClose calls could interleave or tear lines
+	// written to a shared io.Writer, breaking the deterministic-parse
+	// guarantee ParseStatsLine depends on.
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	fmt.Fprintln(w, FormatStatsLine(entry))
+}
+
+// baseTransport builds the innermost *http.Transport for host, using the
+// Client's configured timeouts and TLS settings. Middlewares registered via
+// Use, including the built-in stats Middleware, wrap around it.
+func (c *Client) baseTransport(host string) http.RoundTripper {
+	dialtimeout := 30 * time.Second
+	if c.DialTimeout > 0 {
+		dialtimeout = time.Duration(c.DialTimeout) * time.Second
+	}
+
+	keepalive := 1800 * time.Second
+	if c.KeepaliveTimeout > 0 {
+		keepalive = time.Duration(c.KeepaliveTimeout) * time.Second
+	}
+
+	tlstimeout := 30 * time.Second
+	if c.TLSTimeout > 0 {
+		tlstimeout = time.Duration(c.TLSTimeout) * time.Second
+	}
+
+	dialer := &net.Dialer{Timeout: dialtimeout, KeepAlive: keepalive}
+
+	return &http.Transport{
+		Proxy:               proxyFromClient(c),
+		Dial:                dialer.Dial,
+		TLSHandshakeTimeout: tlstimeout,
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: c.SkipSSLVerify},
+	}
+}
+
+// proxyFromClient returns an http.Transport Proxy func honoring the Client's
+// HTTPSProxy/HTTPProxy/NoProxy settings, falling back to the environment the
+// way http.ProxyFromEnvironment does.
+func proxyFromClient(c *Client) func(*http.Request) (*url.URL, error) {
+	return func(req *http.Request) (*url.URL, error) {
+		host := req.URL.Hostname()
+		for _, no := range strings.Split(c.NoProxy, ",") {
+			no = strings.TrimSpace(no)
+			if len(no) > 0 && host == no {
+				return nil, nil
+			}
+		}
+
+		proxy := c.HTTPProxy
+		if req.URL.Scheme == "https" {
+			proxy = c.HTTPSProxy
+		}
+
+		if len(proxy) == 0 {
+			return http.ProxyFromEnvironment(req)
+		}
+
+		return url.Parse(proxy)
+	}
+}
@@ -0,0 +1,239 @@
+package lfsapi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/git-lfs/git-lfs/errors"
+	jwt "github.com/golang-jwt/jwt"
+)
+
+// jwtConfig holds the `lfs.<host>.jwt.*` settings used to mint bearer tokens
+// for a single LFS endpoint.
+type jwtConfig struct {
+	KeyFile  string
+	Issuer   string
+	Audience string
+	TTL      time.Duration
+	TokenCmd string
+}
+
+// jwtConfigFor reads the JWT settings for host out of env, returning ok=false
+// if none are configured.
+func jwtConfigFor(env Env, host string) (jwtConfig, bool) {
+	prefix := fmt.Sprintf("lfs.%s.jwt.", host)
+
+	keyfile, _ := env.Get(prefix + "keyfile")
+	tokencmd, _ := env.Get(prefix + "tokencmd")
+	if len(keyfile) == 0 && len(tokencmd) == 0 {
+		return jwtConfig{}, false
+	}
+
+	issuer, _ := env.Get(prefix + "issuer")
+	audience, _ := env.Get(prefix + "audience")
+	ttl := env.Int(prefix+"ttl", 300)
+
+	return jwtConfig{
+		KeyFile:  keyfile,
+		Issuer:   issuer,
+		Audience: audience,
+		TTL:      time.Duration(ttl) * time.Second,
+		TokenCmd: tokencmd,
+	}, true
+}
+
+// jwtToken is a signed token cached for a host, alongside when it stops being
+// usable.
+type jwtToken struct {
+	raw       string
+	expiresAt time.Time
+}
+
+func (t *jwtToken) valid(now time.Time) bool {
+	return t != nil && now.Before(t.expiresAt)
+}
+
+// jwtCredentialHelper obtains and refreshes JWT bearer tokens for LFS
+// endpoints configured with `lfs.<host>.jwt.*` settings, caching a signed
+// token per host and re-signing it shortly before it expires. It is selected
+// by NewClient instead of commandCredentialHelper whenever JWT config keys
+// are present for a host, so those endpoints authenticate with
+// "Authorization: Bearer <token>" instead of Basic or NTLM.
+type jwtCredentialHelper struct {
+	Env Env
+
+	mu     sync.Mutex
+	tokens map[string]*jwtToken
+}
+
+func newJWTCredentialHelper(env Env) *jwtCredentialHelper {
+	return &jwtCredentialHelper{
+		Env:    env,
+		tokens: make(map[string]*jwtToken),
+	}
+}
+
+func (h *jwtCredentialHelper) Fill(creds Creds) (Creds, error) {
+	host := creds["host"]
+
+	cfg, ok := jwtConfigFor(h.Env, host)
+	if !ok {
+		return nil, errors.Errorf("lfsapi: no JWT config for %q", host)
+	}
+
+	token, err := h.token(host, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(Creds)
+	for k, v := range creds {
+		out[k] = v
+	}
+	out["authtype"] = "Bearer"
+	out["password"] = token.raw
+
+	return out, nil
+}
+
+// Approve is a no-op: there is no remote store of JWTs to update on success,
+// unlike the username/password git-credential stores.
+func (h *jwtCredentialHelper) Approve(creds Creds) error { return nil }
+
+// Reject drops the cached token for the host so the next Fill re-signs or
+// re-fetches one, instead of handing back the same rejected token.
+func (h *jwtCredentialHelper) Reject(creds Creds) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.tokens, creds["host"])
+	return nil
+}
+
+// token returns a cached, still-valid token for host, or signs/fetches a new
+// one.
+func (h *jwtCredentialHelper) token(host string, cfg jwtConfig) (*jwtToken, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if t, ok := h.tokens[host]; ok && t.valid(time.Now().Add(30*time.Second)) {
+		return t, nil
+	}
+
+	t, err := h.fetchToken(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	h.tokens[host] = t
+	return t, nil
+}
+
+func (h *jwtCredentialHelper) fetchToken(cfg jwtConfig) (*jwtToken, error) {
+	if len(cfg.TokenCmd) > 0 {
+		return h.execTokenCmd(cfg)
+	}
+	return h.signToken(cfg)
+}
+
+// execTokenCmd shells out to `lfs.<host>.jwt.tokencmd` and trusts the
+// resulting JWT's own exp claim, re-running the command once it is near
+// expiry.
+func (h *jwtCredentialHelper) execTokenCmd(cfg jwtConfig) (*jwtToken, error) {
+	out, err := shellCommand(cfg.TokenCmd).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "lfsapi: jwt.tokencmd failed")
+	}
+
+	raw := strings.TrimSpace(string(out))
+
+	expiresAt := time.Now().Add(cfg.TTL)
+	if claims, err := parseJWTClaims(raw); err == nil {
+		if exp, ok := claims["exp"].(float64); ok {
+			expiresAt = time.Unix(int64(exp), 0)
+		}
+	}
+
+	return &jwtToken{raw: raw, expiresAt: expiresAt}, nil
+}
+
+// signToken signs a fresh JWT using the RS256 or HS256 key at cfg.KeyFile,
+// valid for cfg.TTL.
+func (h *jwtCredentialHelper) signToken(cfg jwtConfig) (*jwtToken, error) {
+	keydata, err := ioutil.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, errors.Wrapf(err, "lfsapi: could not read %q", cfg.KeyFile)
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(cfg.TTL)
+
+	claims := jwt.MapClaims{
+		"iat": now.Unix(),
+		"exp": expiresAt.Unix(),
+	}
+	if len(cfg.Issuer) > 0 {
+		claims["iss"] = cfg.Issuer
+	}
+	if len(cfg.Audience) > 0 {
+		claims["aud"] = cfg.Audience
+	}
+
+	signed, err := signJWT(keydata, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &jwtToken{raw: signed, expiresAt: expiresAt}, nil
+}
+
+// signJWT signs claims with an RSA private key if keydata looks like a PEM
+// block, falling back to HS256 with keydata as the shared secret.
+func signJWT(keydata []byte, claims jwt.MapClaims) (string, error) {
+	if key, err := jwt.ParseRSAPrivateKeyFromPEM(keydata); err == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(keydata)
+}
+
+func parseJWTClaims(raw string) (jwt.MapClaims, error) {
+	p := &jwt.Parser{SkipClaimsValidation: true}
+
+	var claims jwt.MapClaims
+	_, _, err := p.ParseUnverified(raw, &claims)
+	return claims, err
+}
+
+// hybridCredentialHelper dispatches to jwt for hosts with `lfs.<host>.jwt.*`
+// config, falling back to cmd (ordinarily a commandCredentialHelper) for
+// everything else. NewClient installs one of these as Client.Credentials so
+// a single git process can talk to both JWT-fronted and Basic/NTLM LFS
+// servers.
+type hybridCredentialHelper struct {
+	env Env
+	jwt *jwtCredentialHelper
+	cmd CredentialHelper
+}
+
+func (h *hybridCredentialHelper) helperFor(creds Creds) CredentialHelper {
+	if _, ok := jwtConfigFor(h.env, creds["host"]); ok {
+		return h.jwt
+	}
+	return h.cmd
+}
+
+func (h *hybridCredentialHelper) Fill(creds Creds) (Creds, error) {
+	return h.helperFor(creds).Fill(creds)
+}
+
+func (h *hybridCredentialHelper) Approve(creds Creds) error {
+	return h.helperFor(creds).Approve(creds)
+}
+
+func (h *hybridCredentialHelper) Reject(creds Creds) error {
+	return h.helperFor(creds).Reject(creds)
+}
@@ -39,14 +39,24 @@ type Client struct {
 	LoggingStats     bool
 	VerboseOut       io.Writer
 
+	// StatsWriter receives the logfmt-encoded StatsEntry lines LogStats
+	// writes for every request when LoggingStats is set, so embedders can
+	// redirect the stream to a file or a metrics sink instead of
+	// VerboseOut.
+	StatsWriter io.Writer
+
 	hostClients map[string]*http.Client
 	clientMu    sync.Mutex
 
+	middleware []Middleware
+
 	ntlmSessions map[string]ntlm.ClientSession
 	ntlmMu       sync.Mutex
 
 	transferBuckets  map[string][]*http.Response
 	transferBucketMu sync.Mutex
+
+	statsMu sync.Mutex
 	transfers        map[*http.Response]*httpTransfer
 	transferMu       sync.Mutex
 
@@ -73,8 +83,12 @@ func NewClient(osEnv Env, gitEnv Env) (*Client, error) {
 
 	c := &Client{
 		Endpoints: NewEndpointFinder(gitEnv),
-		Credentials: &commandCredentialHelper{
-			SkipPrompt: !osEnv.Bool("GIT_TERMINAL_PROMPT", true),
+		Credentials: &hybridCredentialHelper{
+			env: gitEnv,
+			jwt: newJWTCredentialHelper(gitEnv),
+			cmd: &commandCredentialHelper{
+				SkipPrompt: !osEnv.Bool("GIT_TERMINAL_PROMPT", true),
+			},
 		},
 		Netrc:               netrc,
 		DialTimeout:         gitEnv.Int("lfs.dialtimeout", 0),
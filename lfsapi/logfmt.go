@@ -0,0 +1,192 @@
+package lfsapi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/git-lfs/git-lfs/errors"
+)
+
+// StatsEntry is one structured record of an HTTP request made through a
+// Client, as emitted by LogStats and consumed by `git lfs logs stats`.
+type StatsEntry struct {
+	Timestamp  time.Time
+	Method     string
+	URL        string
+	Status     int
+	ReqBytes   int64
+	RespBytes  int64
+	DurationMS int64
+	Auth       string
+	Retry      bool
+	Endpoint   string
+}
+
+// FormatStatsLine renders e as a single logfmt line: "key=value" pairs
+// separated by spaces, in a fixed field order, so the output can be read by
+// both humans and ParseStatsLine.
+func FormatStatsLine(e *StatsEntry) string {
+	fields := []string{
+		logfmtField("ts", e.Timestamp.UTC().Format(time.RFC3339Nano)),
+		logfmtField("method", e.Method),
+		logfmtField("url", e.URL),
+		logfmtField("status", strconv.Itoa(e.Status)),
+		logfmtField("req_bytes", strconv.FormatInt(e.ReqBytes, 10)),
+		logfmtField("resp_bytes", strconv.FormatInt(e.RespBytes, 10)),
+		logfmtField("duration_ms", strconv.FormatInt(e.DurationMS, 10)),
+		logfmtField("auth", e.Auth),
+		logfmtField("retry", strconv.FormatBool(e.Retry)),
+		logfmtField("endpoint", e.Endpoint),
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// logfmtField renders a single "key=value" pair. The empty string is
+// rendered as a bare, unquoted "-" sentinel; a literal "-" value is quoted
+// (as are values containing a space, quote, or "=") so it can never be
+// mistaken for that sentinel on the way back through splitLogfmtFields.
+func logfmtField(key, value string) string {
+	switch {
+	case len(value) == 0:
+		value = "-"
+	case value == "-" || strings.ContainsAny(value, " \"="):
+		value = strconv.Quote(value)
+	}
+
+	return fmt.Sprintf("%s=%s", key, value)
+}
+
+// ParseStatsLine parses a line emitted by FormatStatsLine back into a
+// StatsEntry, so tests and `git lfs logs stats` can consume the stream
+// deterministically instead of regex-scraping free-form text.
+func ParseStatsLine(line string) (*StatsEntry, error) {
+	fields, err := splitLogfmtFields(line)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &StatsEntry{}
+	for k, v := range fields {
+		switch k {
+		case "ts":
+			if len(v) > 0 {
+				e.Timestamp, err = time.Parse(time.RFC3339Nano, v)
+				if err != nil {
+					return nil, errors.Wrapf(err, "lfsapi: invalid ts %q", v)
+				}
+			}
+		case "method":
+			e.Method = v
+		case "url":
+			e.URL = v
+		case "status":
+			e.Status, err = strconv.Atoi(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "lfsapi: invalid status %q", v)
+			}
+		case "req_bytes":
+			e.ReqBytes, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "lfsapi: invalid req_bytes %q", v)
+			}
+		case "resp_bytes":
+			e.RespBytes, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "lfsapi: invalid resp_bytes %q", v)
+			}
+		case "duration_ms":
+			e.DurationMS, err = strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "lfsapi: invalid duration_ms %q", v)
+			}
+		case "auth":
+			e.Auth = v
+		case "retry":
+			e.Retry, err = strconv.ParseBool(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "lfsapi: invalid retry %q", v)
+			}
+		case "endpoint":
+			e.Endpoint = v
+		}
+	}
+
+	return e, nil
+}
+
+// indexClosingQuote returns the index of the closing '"' that matches the
+// opening quote at s[0], skipping over backslash-escaped quotes (`\"`) the
+// way strconv.Quote produces them, or -1 if s has no such quote.
+func indexClosingQuote(s string) int {
+	escaped := false
+	for i := 1; i < len(s); i++ {
+		if escaped {
+			escaped = false
+			continue
+		}
+
+		switch s[i] {
+		case '\\':
+			escaped = true
+		case '"':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// splitLogfmtFields splits a logfmt line into its key/value pairs, honoring
+// double-quoted values produced by logfmtField. A bare (unquoted) "-" is
+// resolved to the empty string here, since logfmtField never emits an
+// unquoted "-" for a literal dash -- only ever for its empty-value sentinel.
+func splitLogfmtFields(line string) (map[string]string, error) {
+	fields := make(map[string]string)
+
+	rest := strings.TrimSpace(line)
+	for len(rest) > 0 {
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			return nil, errors.Errorf("lfsapi: malformed logfmt line %q", line)
+		}
+
+		key := rest[:eq]
+		rest = rest[eq+1:]
+
+		var value string
+		if len(rest) > 0 && rest[0] == '"' {
+			end := indexClosingQuote(rest)
+			if end < 0 {
+				return nil, errors.Errorf("lfsapi: unterminated quoted value in %q", line)
+			}
+
+			quoted, err := strconv.Unquote(rest[:end+1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "lfsapi: invalid quoted value in %q", line)
+			}
+
+			value = quoted
+			rest = strings.TrimSpace(rest[end+1:])
+		} else {
+			sp := strings.IndexByte(rest, ' ')
+			if sp < 0 {
+				value = rest
+				rest = ""
+			} else {
+				value = rest[:sp]
+				rest = strings.TrimSpace(rest[sp+1:])
+			}
+
+			if value == "-" {
+				value = ""
+			}
+		}
+
+		fields[key] = value
+	}
+
+	return fields, nil
+}
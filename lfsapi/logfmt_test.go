@@ -0,0 +1,118 @@
+package lfsapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsLineRoundTrip(t *testing.T) {
+	cases := []*StatsEntry{
+		{
+			Timestamp:  time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+			Method:     "GET",
+			URL:        "https://git-lfs.example.com/objects/batch",
+			Status:     200,
+			ReqBytes:   128,
+			RespBytes:  4096,
+			DurationMS: 42,
+			Auth:       "Bearer",
+			Retry:      false,
+			Endpoint:   "git-lfs.example.com",
+		},
+		{
+			// A failed request: no response, no auth, no endpoint.
+			Method: "POST",
+			URL:    "https://git-lfs.example.com/objects/batch",
+			Status: 0,
+			Retry:  true,
+		},
+		{
+			// A value that needs quoting because it contains a space.
+			Method: "GET",
+			URL:    "https://git-lfs.example.com/objects/batch?note=a b",
+			Auth:   "none",
+		},
+		{
+			// A value that needs quoting because it contains a quote.
+			Method: "GET",
+			URL:    `https://git-lfs.example.com/objects/batch?note="quoted"`,
+		},
+		{
+			// A literal "-" value must not be confused with the
+			// empty-value sentinel.
+			Method: "-",
+			URL:    "https://git-lfs.example.com/objects/batch",
+		},
+	}
+
+	for _, want := range cases {
+		line := FormatStatsLine(want)
+
+		got, err := ParseStatsLine(line)
+		if err != nil {
+			t.Fatalf("ParseStatsLine(%q): %v", line, err)
+		}
+
+		if !got.Timestamp.Equal(want.Timestamp) {
+			t.Errorf("Timestamp: got %v, want %v", got.Timestamp, want.Timestamp)
+		}
+		if got.Method != want.Method {
+			t.Errorf("Method: got %q, want %q", got.Method, want.Method)
+		}
+		if got.URL != want.URL {
+			t.Errorf("URL: got %q, want %q", got.URL, want.URL)
+		}
+		if got.Status != want.Status {
+			t.Errorf("Status: got %d, want %d", got.Status, want.Status)
+		}
+		if got.ReqBytes != want.ReqBytes {
+			t.Errorf("ReqBytes: got %d, want %d", got.ReqBytes, want.ReqBytes)
+		}
+		if got.RespBytes != want.RespBytes {
+			t.Errorf("RespBytes: got %d, want %d", got.RespBytes, want.RespBytes)
+		}
+		if got.DurationMS != want.DurationMS {
+			t.Errorf("DurationMS: got %d, want %d", got.DurationMS, want.DurationMS)
+		}
+		if got.Auth != want.Auth {
+			t.Errorf("Auth: got %q, want %q", got.Auth, want.Auth)
+		}
+		if got.Retry != want.Retry {
+			t.Errorf("Retry: got %v, want %v", got.Retry, want.Retry)
+		}
+		if got.Endpoint != want.Endpoint {
+			t.Errorf("Endpoint: got %q, want %q", got.Endpoint, want.Endpoint)
+		}
+	}
+}
+
+func TestParseStatsLineEmptyAndDashAreDistinct(t *testing.T) {
+	empty, err := ParseStatsLine(FormatStatsLine(&StatsEntry{Method: ""}))
+	if err != nil {
+		t.Fatalf("ParseStatsLine: %v", err)
+	}
+	if empty.Method != "" {
+		t.Fatalf("Method: got %q, want empty string", empty.Method)
+	}
+
+	dash, err := ParseStatsLine(FormatStatsLine(&StatsEntry{Method: "-"}))
+	if err != nil {
+		t.Fatalf("ParseStatsLine: %v", err)
+	}
+	if dash.Method != "-" {
+		t.Fatalf("Method: got %q, want literal \"-\"", dash.Method)
+	}
+}
+
+func TestParseStatsLineMalformed(t *testing.T) {
+	cases := []string{
+		"not-a-field",
+		`method="unterminated`,
+	}
+
+	for _, line := range cases {
+		if _, err := ParseStatsLine(line); err == nil {
+			t.Errorf("ParseStatsLine(%q): expected error, got nil", line)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package lfsapi
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Middleware wraps an http.RoundTripper to add cross-cutting behavior
+// (retries, tracing, request signing, metrics, etc) around every request a
+// Client makes. Middlewares are applied to the per-host *http.Client
+// transports built in httpClient(), so registering one affects batch,
+// transfer, and locking API calls alike without touching doWithAuth.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// Use registers a Middleware that wraps the RoundTripper used for every host
+// this Client talks to. Middlewares run in the order they are registered: the
+// first Middleware passed to Use is the outermost wrapper, so it sees the
+// request first and the response last. Use must be called before the first
+// request to a given host, since per-host clients are built and cached
+// lazily in httpClient().
+func (c *Client) Use(m Middleware) {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+
+	c.middleware = append(c.middleware, m)
+}
+
+// wrap applies every registered Middleware to rt, last-registered first, so
+// that the first Middleware registered ends up as the outermost wrapper.
+func (c *Client) wrap(rt http.RoundTripper) http.RoundTripper {
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		rt = c.middleware[i](rt)
+	}
+	return rt
+}
+
+// roundTripperFunc adapts an ordinary function to the http.RoundTripper
+// interface, the same way http.HandlerFunc adapts a function to
+// http.Handler.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// httpClient returns the *http.Client used for requests to host, building and
+// caching one the first time it is asked for. Every Middleware registered
+// with Use is applied to the client's transport, innermost base transport
+// last.
+func (c *Client) httpClient(host string) *http.Client {
+	c.clientMu.Lock()
+	defer c.clientMu.Unlock()
+
+	if c.hostClients == nil {
+		c.hostClients = make(map[string]*http.Client)
+	}
+
+	if client, ok := c.hostClients[host]; ok {
+		return client
+	}
+
+	tr := c.wrap(c.statsRoundTripper(c.baseTransport(host)))
+	client := &http.Client{Transport: tr}
+	c.hostClients[host] = client
+
+	return client
+}
+
+// statsRoundTripper is the built-in Middleware that measures per-request
+// metrics -- response status, bytes actually written and read, and
+// wall-clock duration -- without requiring any changes to handler code. It
+// wraps the request and response bodies in counting readers rather than
+// trusting Content-Length, since chunked and gzipped transfers (the common
+// case for object up/downloads through this client) frequently omit it.
+// Captured metrics flow through LogStats into the existing
+// LoggingStats/StatsWriter/VerboseOut pipeline, including requests that fail
+// outright.
+func (c *Client) statsRoundTripper(next http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if !c.LoggingStats {
+			return next.RoundTrip(req)
+		}
+
+		start := time.Now()
+
+		var reqCounter *countingReadCloser
+		if req.Body != nil {
+			reqCounter = &countingReadCloser{rc: req.Body}
+			req.Body = reqCounter
+		}
+
+		res, err := next.RoundTrip(req)
+		if err != nil {
+			c.LogStats(&httpTransfer{
+				Key:         sanitizedURL(req.URL),
+				Method:      req.Method,
+				Status:      0,
+				RequestSize: reqCounter.count(),
+				Start:       start,
+				Dur:         time.Since(start),
+				Auth:        authScheme(req),
+				Retry:       len(req.Header.Get("X-LFS-Retry")) > 0,
+				Endpoint:    req.URL.Host,
+			})
+			return res, err
+		}
+
+		respCounter := &countingReadCloser{rc: res.Body}
+		respCounter.onClose = func() {
+			c.LogStats(&httpTransfer{
+				Key:          sanitizedURL(req.URL),
+				Method:       req.Method,
+				Status:       res.StatusCode,
+				RequestSize:  reqCounter.count(),
+				ResponseSize: respCounter.count(),
+				Start:        start,
+				Dur:          time.Since(start),
+				Auth:         authScheme(req),
+				Retry:        len(req.Header.Get("X-LFS-Retry")) > 0,
+				Endpoint:     req.URL.Host,
+				Response:     res,
+			})
+		}
+		res.Body = respCounter
+
+		return res, nil
+	})
+}
+
+// authScheme returns the scheme name (e.g. "Bearer", "Basic") req
+// authenticated with, or "none" if it carried no Authorization header.
+func authScheme(req *http.Request) string {
+	auth := req.Header.Get("Authorization")
+	if len(auth) == 0 {
+		return "none"
+	}
+
+	if i := strings.IndexByte(auth, ' '); i > 0 {
+		return auth[:i]
+	}
+
+	return auth
+}
+
+// countingReadCloser wraps an io.ReadCloser, counting the bytes actually
+// read through it and firing onClose (once) when Close is called, which for
+// a response body happens after the caller has finished reading it -- the
+// only point at which its real size is known.
+type countingReadCloser struct {
+	rc      io.ReadCloser
+	n       int64
+	onClose func()
+	closed  bool
+}
+
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReadCloser) Close() error {
+	err := c.rc.Close()
+	if !c.closed {
+		c.closed = true
+		if c.onClose != nil {
+			c.onClose()
+		}
+	}
+	return err
+}
+
+// count returns how many bytes have been read through c, or 0 if c is nil
+// (e.g. a GET request with no body).
+func (c *countingReadCloser) count() int64 {
+	if c == nil {
+		return 0
+	}
+	return c.n
+}
@@ -0,0 +1,12 @@
+// +build !windows
+
+package lfsapi
+
+import "os/exec"
+
+// shellCommand runs cmdline through the platform shell, the way
+// `jwt.tokencmd` and similar user-supplied command strings are expected to
+// be interpreted.
+func shellCommand(cmdline string) *exec.Cmd {
+	return exec.Command("sh", "-c", cmdline)
+}